@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthConfig controls who may call /print and how hard they may hit it.
+type AuthConfig struct {
+	Tokens            []string `json:"tokens"`
+	HMACSecret        string   `json:"hmac_secret"`
+	AllowedOrigins    []string `json:"allowed_origins"`
+	RequestsPerMinute int      `json:"requests_per_minute"`
+	BytesPerMinute    int      `json:"bytes_per_minute"`
+}
+
+// maxSignatureAge bounds how stale an HMAC-signed request's timestamp may
+// be before it's rejected as a possible replay.
+const maxSignatureAge = 5 * time.Minute
+
+// authEnabled reports whether any auth mechanism has been configured. Until
+// an operator opts in by setting tokens or a HMAC secret, /print stays open
+// so existing deployments don't break on upgrade.
+func authEnabled() bool {
+	return len(config.Auth.Tokens) > 0 || config.Auth.HMACSecret != ""
+}
+
+// authenticate checks the request against the configured bearer tokens or
+// HMAC signature and returns an identity to key rate limiting by.
+func authenticate(req *http.Request, body []byte) (string, error) {
+	if token, ok := bearerToken(req); ok {
+		for _, valid := range config.Auth.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+				return "token:" + token, nil
+			}
+		}
+		return "", fmt.Errorf("invalid bearer token")
+	}
+
+	signature := req.Header.Get("X-Signature")
+	timestamp := req.Header.Get("X-Timestamp")
+	if signature != "" && timestamp != "" {
+		return authenticateHMAC(signature, timestamp, body)
+	}
+
+	return "", fmt.Errorf("missing credentials")
+}
+
+// requireBearerToken gates a read-only endpoint (job and printer
+// inspection) behind the configured bearer tokens whenever auth has been
+// enabled. Unlike authenticate, it doesn't accept HMAC signatures, since
+// these endpoints have no request body to sign. It writes the error
+// response itself and reports whether the caller should proceed.
+func requireBearerToken(w http.ResponseWriter, req *http.Request) bool {
+	if !authEnabled() {
+		return true
+	}
+
+	token, ok := bearerToken(req)
+	if !ok {
+		http.Error(w, "missing credentials", http.StatusUnauthorized)
+		return false
+	}
+	for _, valid := range config.Auth.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+			return true
+		}
+	}
+	http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+	return false
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimPrefix(header, "Bearer "), true
+}
+
+// authenticateHMAC validates an X-Signature/X-Timestamp pair against the
+// configured shared secret, rejecting timestamps older than
+// maxSignatureAge to prevent replays.
+func authenticateHMAC(signature, timestamp string, body []byte) (string, error) {
+	if config.Auth.HMACSecret == "" {
+		return "", fmt.Errorf("HMAC auth is not configured")
+	}
+
+	tsUnix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid X-Timestamp")
+	}
+	age := time.Since(time.Unix(tsUnix, 0))
+	if age > maxSignatureAge || age < -maxSignatureAge {
+		return "", fmt.Errorf("X-Timestamp outside allowed window")
+	}
+
+	mac := hmac.New(sha256.New, []byte(config.Auth.HMACSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return "", fmt.Errorf("invalid X-Signature")
+	}
+	return "hmac", nil
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value to send for a
+// given request Origin, or "" if it isn't on the allow-list.
+func allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range config.Auth.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return allowed
+		}
+	}
+	return ""
+}
+
+// bucket is a token-bucket pair tracking both a requests/minute and a
+// bytes/minute budget for a single identity.
+type bucket struct {
+	requestTokens float64
+	byteTokens    float64
+	lastRefill    time.Time
+}
+
+// RateLimiter enforces per-identity requests/minute and bytes/minute caps
+// so a single misbehaving or compromised client can't flood the spooler.
+type RateLimiter struct {
+	mu                sync.Mutex
+	buckets           map[string]*bucket
+	requestsPerMinute int
+	bytesPerMinute    int
+}
+
+// NewRateLimiter builds a limiter from the configured per-minute budgets. A
+// budget of 0 means that dimension is unlimited.
+func NewRateLimiter(requestsPerMinute, bytesPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		buckets:           make(map[string]*bucket),
+		requestsPerMinute: requestsPerMinute,
+		bytesPerMinute:    bytesPerMinute,
+	}
+}
+
+// Allow reports whether a request of the given size should be admitted for
+// the given identity, consuming from its buckets if so.
+func (l *RateLimiter) Allow(identity string, size int) bool {
+	if l.requestsPerMinute <= 0 && l.bytesPerMinute <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[identity]
+	if !ok {
+		b = &bucket{
+			requestTokens: float64(l.requestsPerMinute),
+			byteTokens:    float64(l.bytesPerMinute),
+			lastRefill:    now,
+		}
+		l.buckets[identity] = b
+	}
+
+	elapsedMinutes := now.Sub(b.lastRefill).Minutes()
+	b.lastRefill = now
+	if l.requestsPerMinute > 0 {
+		b.requestTokens = math.Min(float64(l.requestsPerMinute), b.requestTokens+elapsedMinutes*float64(l.requestsPerMinute))
+	}
+	if l.bytesPerMinute > 0 {
+		b.byteTokens = math.Min(float64(l.bytesPerMinute), b.byteTokens+elapsedMinutes*float64(l.bytesPerMinute))
+	}
+
+	if l.requestsPerMinute > 0 && b.requestTokens < 1 {
+		return false
+	}
+	if l.bytesPerMinute > 0 && b.byteTokens < float64(size) {
+		return false
+	}
+
+	if l.requestsPerMinute > 0 {
+		b.requestTokens--
+	}
+	if l.bytesPerMinute > 0 {
+		b.byteTokens -= float64(size)
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllowsWithinBudgetThenBlocks(t *testing.T) {
+	l := NewRateLimiter(2, 0)
+	if !l.Allow("id", 10) {
+		t.Fatal("first request should be allowed")
+	}
+	if !l.Allow("id", 10) {
+		t.Fatal("second request should be allowed")
+	}
+	if l.Allow("id", 10) {
+		t.Fatal("third request should be rate limited")
+	}
+}
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	l := NewRateLimiter(120, 0) // 120 req/min == 2 tokens/sec
+	for i := 0; i < 120; i++ {
+		if !l.Allow("id", 0) {
+			t.Fatalf("expected request %d to be within the initial budget", i)
+		}
+	}
+	if l.Allow("id", 0) {
+		t.Fatal("expected the budget to be exhausted")
+	}
+	time.Sleep(600 * time.Millisecond)
+	if !l.Allow("id", 0) {
+		t.Fatal("expected the bucket to have refilled after ~0.6s")
+	}
+}
+
+func TestRateLimiterEnforcesByteBudget(t *testing.T) {
+	l := NewRateLimiter(0, 100)
+	if !l.Allow("id", 60) {
+		t.Fatal("expected a 60-byte request to fit the 100 byte/min budget")
+	}
+	if l.Allow("id", 60) {
+		t.Fatal("expected a second 60-byte request to exceed the budget")
+	}
+}
+
+func TestAuthenticateHMACTimestampWindow(t *testing.T) {
+	oldAuth := config.Auth
+	defer func() { config.Auth = oldAuth }()
+	config.Auth = AuthConfig{HMACSecret: "topsecret"}
+
+	body := []byte("payload")
+	sign := func() string {
+		mac := hmac.New(sha256.New, []byte(config.Auth.HMACSecret))
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	fresh := strconv.FormatInt(time.Now().Unix(), 10)
+	if _, err := authenticateHMAC(sign(), fresh, body); err != nil {
+		t.Fatalf("expected a fresh timestamp to authenticate, got %v", err)
+	}
+
+	stale := strconv.FormatInt(time.Now().Add(-6*time.Minute).Unix(), 10)
+	if _, err := authenticateHMAC(sign(), stale, body); err == nil {
+		t.Fatal("expected a timestamp older than maxSignatureAge to be rejected")
+	}
+
+	if _, err := authenticateHMAC("deadbeef", fresh, body); err == nil {
+		t.Fatal("expected an invalid signature to be rejected")
+	}
+}
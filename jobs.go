@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// JobState represents the lifecycle state of a PrintJob.
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// PrintJob is the first-class representation of a single print request,
+// tracked from the moment it is accepted until the OS-level command
+// finishes (or fails).
+type PrintJob struct {
+	ID         string     `json:"id"`
+	FileName   string     `json:"file_name"`
+	Printer    string     `json:"printer"`
+	State      JobState   `json:"state"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Output     string     `json:"output,omitempty"`
+
+	mu        sync.Mutex
+	converter Converter
+	profile   PrinterProfile
+	info      PrintInfo
+}
+
+func (j *PrintJob) setStarted() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.StartedAt = &now
+	j.State = JobRunning
+}
+
+func (j *PrintJob) setFinished(output string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.FinishedAt = &now
+	j.Output = output
+	if err != nil {
+		j.State = JobFailed
+		j.Error = err.Error()
+	} else {
+		j.State = JobSucceeded
+	}
+}
+
+// snapshot returns a copy of the job safe to marshal without racing on mu.
+func (j *PrintJob) snapshot() PrintJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return PrintJob{
+		ID:         j.ID,
+		FileName:   j.FileName,
+		Printer:    j.Printer,
+		State:      j.State,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+		Error:      j.Error,
+		Output:     j.Output,
+	}
+}
+
+// JobRegistry is an in-memory store of PrintJobs backed by a bounded worker
+// pool. Jobs are accepted instantly and executed asynchronously, so callers
+// can poll for completion instead of blocking the HTTP handler.
+type JobRegistry struct {
+	mu    sync.RWMutex
+	jobs  map[string]*PrintJob
+	queue chan *PrintJob
+}
+
+// NewJobRegistry starts the given number of worker goroutines (at least
+// one; 0 is treated as 1) consuming from an internal queue and returns the
+// registry used to enqueue and inspect jobs.
+func NewJobRegistry(workers uint) *JobRegistry {
+	if workers == 0 {
+		workers = 1
+	}
+	r := &JobRegistry{
+		jobs:  make(map[string]*PrintJob),
+		queue: make(chan *PrintJob, 256),
+	}
+	for i := uint(0); i < workers; i++ {
+		go r.worker()
+	}
+	return r
+}
+
+func (r *JobRegistry) worker() {
+	for job := range r.queue {
+		job.setStarted()
+		appLog.Tracef("spool", job.ID, "Job started for printer %q", job.Printer)
+
+		cmd := job.converter.Command(job.FileName, job.profile.QueueName, job.info, job.profile)
+		output, err := cmd.CombinedOutput()
+		job.setFinished(string(output), err)
+
+		if err != nil {
+			appLog.Errorf(job.ID, "Job failed: %v", err)
+		} else {
+			appLog.Infof(job.ID, "Job finished successfully")
+		}
+	}
+}
+
+// Enqueue creates a new PrintJob in the queued state and schedules it for
+// execution, returning immediately unless the queue's 256-job buffer is
+// full, in which case it blocks until a worker frees a slot. requestID ties the job back to the
+// HTTP request that created it for log correlation; converter determines
+// how the spooled file is handed off to the printer.
+func (r *JobRegistry) Enqueue(requestID, fileName string, profile PrinterProfile, converter Converter, info PrintInfo) *PrintJob {
+	job := &PrintJob{
+		ID:        requestID,
+		FileName:  fileName,
+		Printer:   profile.QueueName,
+		State:     JobQueued,
+		CreatedAt: time.Now(),
+		converter: converter,
+		profile:   profile,
+		info:      info,
+	}
+
+	r.mu.Lock()
+	r.jobs[job.ID] = job
+	r.mu.Unlock()
+
+	r.queue <- job
+	return job
+}
+
+// Get returns the job with the given ID, or nil if it doesn't exist.
+func (r *JobRegistry) Get(id string) (*PrintJob, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[id]
+	return job, ok
+}
+
+// List returns a snapshot of every known job.
+func (r *JobRegistry) List() []PrintJob {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]PrintJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		out = append(out, job.snapshot())
+	}
+	return out
+}
+
+// Pending returns the number of jobs that are still queued or running.
+func (r *JobRegistry) Pending() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	count := 0
+	for _, job := range r.jobs {
+		switch job.snapshot().State {
+		case JobQueued, JobRunning:
+			count++
+		}
+	}
+	return count
+}
+
+// Drain blocks until every job has finished or ctx is done, whichever comes
+// first. It returns true if the queue fully drained.
+func (r *JobRegistry) Drain(ctx context.Context) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if r.Pending() == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// newJobID generates a random UUID-like identifier without pulling in an
+// external dependency.
+func newJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("job-%d", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJobRegistryDrainCompletesWhenJobsFinish(t *testing.T) {
+	r := &JobRegistry{jobs: make(map[string]*PrintJob), queue: make(chan *PrintJob, 1)}
+	job := &PrintJob{ID: "1", State: JobRunning}
+	r.jobs[job.ID] = job
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		job.setFinished("", nil)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if !r.Drain(ctx) {
+		t.Fatal("expected Drain to report success once all jobs finish")
+	}
+}
+
+func TestJobRegistryDrainTimesOutWithJobsStillRunning(t *testing.T) {
+	r := &JobRegistry{jobs: make(map[string]*PrintJob), queue: make(chan *PrintJob, 1)}
+	job := &PrintJob{ID: "1", State: JobRunning}
+	r.jobs[job.ID] = job
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if r.Drain(ctx) {
+		t.Fatal("expected Drain to time out while a job is still running")
+	}
+}
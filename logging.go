@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel is the minimum severity a log record must have to be emitted.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+func parseLogLevel(raw string) LogLevel {
+	switch strings.ToUpper(strings.TrimSpace(raw)) {
+	case "DEBUG":
+		return LevelDebug
+	case "WARN", "WARNING":
+		return LevelWarn
+	case "ERROR":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// record is one emitted log line, shared by every handler so sinks only
+// need to know how to format it.
+type record struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`
+	RequestID string    `json:"request_id,omitempty"`
+	Trace     string    `json:"trace,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// LogHandler writes a single log record to its sink.
+type LogHandler interface {
+	Handle(rec record)
+}
+
+// textHandler formats records as human-readable lines; it's the default
+// stderr sink, replaced by jsonHandler when JSON logging is enabled.
+type textHandler struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (h *textHandler) Handle(rec record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prefix := rec.Time.Format("2006/01/02 15:04:05")
+	if rec.RequestID != "" {
+		prefix += " [" + rec.RequestID + "]"
+	}
+	if rec.Trace != "" {
+		prefix += " (" + rec.Trace + ")"
+	}
+	fmt.Fprintf(h.out, "%s %s %s\n", prefix, rec.Level, rec.Message)
+}
+
+// jsonHandler formats records as one JSON object per line.
+type jsonHandler struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (h *jsonHandler) Handle(rec record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_ = json.NewEncoder(h.out).Encode(rec)
+}
+
+// fileHandler writes text-formatted records to a file, rotating it once it
+// grows past maxBytes. A maxBytes of 0 disables rotation.
+type fileHandler struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	size     int64
+}
+
+func newFileHandler(path string, maxBytes int64) (*fileHandler, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileHandler{path: path, maxBytes: maxBytes, file: f, size: info.Size()}, nil
+}
+
+func (h *fileHandler) Handle(rec record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	line := fmt.Sprintf("%s [%s] %s %s\n", rec.Time.Format(time.RFC3339), rec.RequestID, rec.Level, rec.Message)
+	n, err := h.file.WriteString(line)
+	if err != nil {
+		return
+	}
+	h.size += int64(n)
+
+	if h.maxBytes > 0 && h.size >= h.maxBytes {
+		h.rotate()
+	}
+}
+
+func (h *fileHandler) rotate() {
+	h.file.Close()
+	rotated := h.path + "." + strconv.FormatInt(time.Now().Unix(), 10)
+	_ = os.Rename(h.path, rotated)
+
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	h.file = f
+	h.size = 0
+}
+
+// LoggingConfig controls the leveled logger's extra sinks and rotation.
+// A stderr sink is always on: text by default, JSON when JSON is set.
+type LoggingConfig struct {
+	JSON        bool   `json:"json"`
+	FilePath    string `json:"file_path"`
+	MaxFileSize int64  `json:"max_file_size"`
+}
+
+// Logger is a small leveled logger with pluggable sinks and an opt-in trace
+// selector for noisy subsystems.
+type Logger struct {
+	level    LogLevel
+	handlers []LogHandler
+	trace    map[string]bool
+}
+
+// NewLogger builds a Logger from the given config plus the LOG_LEVEL and
+// LOG_TRACE environment variables. LOG_LEVEL overrides the config's Debug
+// flag when set; LOG_TRACE is a comma-separated list of subsystem names
+// (e.g. "spool,cleanup") to enable debug-level tracing for.
+func NewLogger(cfg LoggingConfig, debug bool) (*Logger, error) {
+	level := LevelInfo
+	if debug {
+		level = LevelDebug
+	}
+	if raw := os.Getenv("LOG_LEVEL"); raw != "" {
+		level = parseLogLevel(raw)
+	}
+
+	// JSON replaces the default text sink rather than stacking on top of it,
+	// so enabling it doesn't double-log every line to stderr.
+	var stderrHandler LogHandler = &textHandler{out: os.Stderr}
+	if cfg.JSON {
+		stderrHandler = &jsonHandler{out: os.Stderr}
+	}
+
+	l := &Logger{
+		level:    level,
+		handlers: []LogHandler{stderrHandler},
+		trace:    parseTraceSelector(os.Getenv("LOG_TRACE")),
+	}
+
+	if cfg.FilePath != "" {
+		fh, err := newFileHandler(cfg.FilePath, cfg.MaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		l.handlers = append(l.handlers, fh)
+	}
+
+	return l, nil
+}
+
+func parseTraceSelector(raw string) map[string]bool {
+	trace := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			trace[name] = true
+		}
+	}
+	return trace
+}
+
+func (l *Logger) log(level LogLevel, requestID, trace, format string, args ...interface{}) {
+	// A trace selector means Tracef already decided this line is wanted
+	// regardless of the configured level, so the level gate only applies to
+	// the untraced Debugf/Infof/Warnf/Errorf calls.
+	if trace == "" && level < l.level {
+		return
+	}
+	rec := record{
+		Time:      time.Now(),
+		Level:     level.String(),
+		RequestID: requestID,
+		Trace:     trace,
+		Message:   fmt.Sprintf(format, args...),
+	}
+	for _, h := range l.handlers {
+		h.Handle(rec)
+	}
+}
+
+// Debugf logs at debug level, tagged with requestID when one is available.
+func (l *Logger) Debugf(requestID, format string, args ...interface{}) {
+	l.log(LevelDebug, requestID, "", format, args...)
+}
+
+// Infof logs at info level.
+func (l *Logger) Infof(requestID, format string, args ...interface{}) {
+	l.log(LevelInfo, requestID, "", format, args...)
+}
+
+// Warnf logs at warn level.
+func (l *Logger) Warnf(requestID, format string, args ...interface{}) {
+	l.log(LevelWarn, requestID, "", format, args...)
+}
+
+// Errorf logs at error level.
+func (l *Logger) Errorf(requestID, format string, args ...interface{}) {
+	l.log(LevelError, requestID, "", format, args...)
+}
+
+// Tracef logs at debug level, gated by the LOG_TRACE selector — use it for
+// a specific subsystem that's usually too noisy to leave on.
+func (l *Logger) Tracef(selector, requestID, format string, args ...interface{}) {
+	if !l.trace[selector] {
+		return
+	}
+	l.log(LevelDebug, requestID, selector, format, args...)
+}
+
+// Fatalf logs at error level then exits the process, mirroring the
+// stdlib log.Fatalf calls it replaces.
+func (l *Logger) Fatalf(requestID, format string, args ...interface{}) {
+	l.Errorf(requestID, format, args...)
+	os.Exit(1)
+}
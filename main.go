@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,38 +11,56 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 )
 
-var printCount = 0
-
 // Config structure to hold printer and debug configuration
 type Config struct {
-	RunOnHttps  bool   `json:"https"`
-	PrinterName string `json:"printer_name"`
-	Debug       bool   `json:"debug"`
-	PrintDir    string `json:"print_dir"`
-	Port        uint   `json:"port"`
+	RunOnHttps      bool                      `json:"https"`
+	Printers        map[string]PrinterProfile `json:"printers"`
+	DefaultPrinter  string                    `json:"default_printer"`
+	Debug           bool                      `json:"debug"`
+	PrintDir        string                    `json:"print_dir"`
+	Port            uint                      `json:"port"`
+	Workers         uint                      `json:"workers"`
+	ShutdownTimeout uint                      `json:"shutdown_timeout"`
+	PidFile         string                    `json:"pid_file"`
+	Auth            AuthConfig                `json:"auth"`
+	Logging         LoggingConfig             `json:"logging"`
 }
 
 // Global variable for the configuration
 var config Config
 
-// Function to log if debug is enabled
-func debugLog(message string) {
-	if config.Debug {
-		log.Println(message)
-	}
-}
+// jobs is the process-wide registry of print jobs, initialized in main once
+// the configured worker count is known.
+var jobs *JobRegistry
+
+// limiter enforces the configured per-token rate limits, initialized in
+// main once the configuration has been read.
+var limiter *RateLimiter
+
+// appLog is the process-wide leveled logger, initialized in main once the
+// configuration has been read.
+var appLog *Logger
 
 // Reads the configuration from a file
 func readConfig(filePath string) (Config, error) {
 	var conf Config = Config{
-		RunOnHttps:  false,
-		PrinterName: "",
-		Debug:       false,
-		PrintDir:    "temp-files",
-		Port:        49155,
+		RunOnHttps:      false,
+		Printers:        map[string]PrinterProfile{},
+		DefaultPrinter:  "",
+		Debug:           false,
+		PrintDir:        "temp-files",
+		Port:            49155,
+		Workers:         2,
+		ShutdownTimeout: 30,
+		PidFile:         "print-server.pid",
+		// Matches the pre-auth behavior (Access-Control-Allow-Origin: *) so
+		// upgrading to this version doesn't silently break browser clients;
+		// operators who configure tokens/hmac_secret can tighten this too.
+		Auth: AuthConfig{AllowedOrigins: []string{"*"}},
 	}
 	file, err := os.ReadFile(filePath)
 	if err != nil {
@@ -53,30 +72,33 @@ func readConfig(filePath string) (Config, error) {
 
 // cleanupPrintJobs deletes all .prn files in the specified print directory
 func cleanupPrintJobs() {
-	debugLog("Starting cleanup of print jobs...")
+	appLog.Tracef("cleanup", "", "Starting cleanup of print jobs...")
 	files, err := filepath.Glob(filepath.Join(config.PrintDir, "*.prn"))
 	if err != nil {
-		log.Printf("Failed to glob for .prn files: %v", err)
+		appLog.Errorf("", "Failed to glob for .prn files: %v", err)
 		return
 	}
 
 	for _, file := range files {
 		err := os.Remove(file)
 		if err != nil {
-			log.Printf("Failed to delete print job file: %s, error: %v", file, err)
+			appLog.Errorf("", "Failed to delete print job file: %s, error: %v", file, err)
 		} else {
-			debugLog(fmt.Sprintf("Deleted leftover print job file: %s", file))
+			appLog.Tracef("cleanup", "", "Deleted leftover print job file: %s", file)
 		}
 	}
-	debugLog("Cleanup of print jobs completed.")
+	appLog.Tracef("cleanup", "", "Cleanup of print jobs completed.")
 }
 
 // Handles incoming print requests
 func printHandler(w http.ResponseWriter, req *http.Request) {
-	// Set CORS headers for preflight and actual request
-	w.Header().Set("Access-Control-Allow-Origin", "*") // Specify the actual origin instead of '*'
+	// Set CORS headers for preflight and actual request, restricted to the
+	// configured allow-list.
+	if origin := allowedOrigin(req.Header.Get("Origin")); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept, Authorization, X-Printer, X-Signature, X-Timestamp")
 	w.Header().Set("Access-Control-Max-Age", "3600") // Max age for the preflight request
 
 	if req.Method == "OPTIONS" {
@@ -89,15 +111,14 @@ func printHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	debugLog("Received print job request.")
+	requestID := newJobID()
+	w.Header().Set("X-Request-ID", requestID)
 
-	//if printCount > 20 {
-	//	debugLog("Printer exhausted, please restart.")
-	//	return
-	//}
+	appLog.Infof(requestID, "Received print job request.")
 
-	// Read the body (expected to be the raw data of the file to print)
-	data, err := io.ReadAll(req.Body)
+	// Buffer the raw body so it can be authenticated (HMAC signs the whole
+	// body) before being re-parsed as a multipart form.
+	rawBody, err := io.ReadAll(req.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -109,6 +130,68 @@ func printHandler(w http.ResponseWriter, req *http.Request) {
 		}
 	}(req.Body)
 
+	if authEnabled() {
+		identity, err := authenticate(req, rawBody)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !limiter.Allow(identity, len(rawBody)) {
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(rawBody))
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid multipart form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := req.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing \"file\" part: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	var info PrintInfo
+	if infoRaw := req.FormValue("info"); infoRaw != "" {
+		if err := json.Unmarshal([]byte(infoRaw), &info); err != nil {
+			http.Error(w, "Invalid \"info\" part: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	printerName, profile, ok := resolvePrinter(req, info.Printer)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown printer: %s", printerName), http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := detectFileType(header.Filename, data)
+	converter, ok := converterFor(contentType)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unsupported file type: %s", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if !printerAccepts(profile, contentType) {
+		http.Error(w, fmt.Sprintf("Printer %q does not accept file type: %s", printerName, contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	spoolData, err := converter.Prepare(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Ensure the print directory exists
 	err = os.MkdirAll(config.PrintDir, 0755)
 	if err != nil {
@@ -118,55 +201,89 @@ func printHandler(w http.ResponseWriter, req *http.Request) {
 
 	// Create a unique file for the print job
 	printJobFileName := filepath.Join(config.PrintDir, fmt.Sprintf("printjob_%d.prn", time.Now().UnixNano()))
-	err = os.WriteFile(printJobFileName, data, 0644)
+	err = os.WriteFile(printJobFileName, spoolData, 0644)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	debugLog(fmt.Sprintf("Created print job file: %s", printJobFileName))
+	appLog.Tracef("spool", requestID, "Created print job file: %s", printJobFileName)
 
 	// Schedule file cleanup after 1 hour
 	time.AfterFunc(1*time.Hour, func() {
 		err := os.Remove(printJobFileName)
 		if err != nil {
-			log.Printf("Failed to delete print job file: %s, error: %v", printJobFileName, err)
+			appLog.Errorf(requestID, "Failed to delete print job file: %s, error: %v", printJobFileName, err)
 		} else {
-			debugLog(fmt.Sprintf("Print job file deleted: %s", printJobFileName))
+			appLog.Tracef("cleanup", requestID, "Print job file deleted: %s", printJobFileName)
 		}
 	})
 
-	// Execute print command based on OS
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		if config.PrinterName == "" {
-			cmd = exec.Command("print", printJobFileName)
-		} else {
+	job := jobs.Enqueue(requestID, printJobFileName, profile, converter, info)
+	appLog.Infof(requestID, "Queued print job on printer %q for file: %s", printerName, printJobFileName)
 
-			cmd = exec.Command("cmd", "/c", "copy", printJobFileName, config.PrinterName)
-		}
-	} else {
-		if config.PrinterName == "" {
-			cmd = exec.Command("lp", printJobFileName)
-		} else {
-			cmd = exec.Command("lp", "-d", config.PrinterName, printJobFileName)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(map[string]string{"job_id": job.ID})
+	if err != nil {
+		return
+	}
+}
+
+// buildPrintCommand constructs the OS-appropriate command used to hand a
+// spooled file off to the printer, without running it.
+func buildPrintCommand(printer, fileName string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		if printer == "" {
+			return exec.Command("print", fileName)
 		}
+		return exec.Command("cmd", "/c", "copy", fileName, printer)
+	}
+	if printer == "" {
+		return exec.Command("lp", fileName)
+	}
+	return exec.Command("lp", "-d", printer, fileName)
+}
+
+// jobHandler returns the state of a single print job addressed by ID, e.g.
+// GET /jobs/<id>.
+func jobHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Only GET method is accepted", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireBearerToken(w, req) {
+		return
 	}
 
-	printCount = printCount + 1
+	id := strings.TrimPrefix(req.URL.Path, "/jobs/")
+	if id == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
 
-	err = cmd.Run()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	job, ok := jobs.Get(id)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
 		return
 	}
 
-	debugLog(fmt.Sprintf("Print job sent to printer: %s", config.PrinterName))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job.snapshot())
+}
 
-	_, err = fmt.Fprintf(w, "Print job %s sent successfully.", printJobFileName)
-	if err != nil {
+// jobsListHandler returns every known print job, e.g. GET /jobs.
+func jobsListHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Only GET method is accepted", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireBearerToken(w, req) {
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(jobs.List())
 }
 
 func ensureDir(dirName string) error {
@@ -184,37 +301,41 @@ func main() {
 		log.Fatalf("Error reading config file: %v", err)
 	}
 
-	debugLog("Starting server...")
-	log.Println("-------------------------------------------------------------")
-	log.Println(fmt.Sprintf("Runs on HTTPS: %v", config.RunOnHttps))
-	log.Println(fmt.Sprintf("Runs on Port: %d", config.Port))
-	if config.PrinterName == "" {
-		log.Println(fmt.Sprintf("Print at %s", "Default Printer"))
-	} else {
-		log.Println(fmt.Sprintf("Print at %s", config.PrinterName))
+	appLog, err = NewLogger(config.Logging, config.Debug)
+	if err != nil {
+		log.Fatalf("Error initializing logger: %v", err)
 	}
-	log.Println(fmt.Sprintf("Debug: %v", config.Debug))
-	log.Println(fmt.Sprintf("Print Directory: %s", config.PrintDir))
-	log.Println("-------------------------------------------------------------")
-	log.Println("")
+
+	appLog.Infof("", "Starting server...")
+	appLog.Infof("", "-------------------------------------------------------------")
+	appLog.Infof("", "Runs on HTTPS: %v", config.RunOnHttps)
+	appLog.Infof("", "Runs on Port: %d", config.Port)
+	appLog.Infof("", "Configured printers: %d (default: %s)", len(config.Printers), config.DefaultPrinter)
+	appLog.Infof("", "Debug: %v", config.Debug)
+	appLog.Infof("", "Print Directory: %s", config.PrintDir)
+	appLog.Infof("", "-------------------------------------------------------------")
 
 	err = ensureDir(config.PrintDir)
 	if err != nil {
-		log.Fatalf("Error creating directory: %v", err)
+		appLog.Fatalf("", "Error creating directory: %v", err)
+	}
+
+	if err := writePidFile(config.PidFile); err != nil {
+		appLog.Fatalf("", "Error writing PID file: %v", err)
 	}
+	defer removePidFile(config.PidFile)
 
 	// Perform initial cleanup of any leftover print job files
 	cleanupPrintJobs()
 
+	jobs = NewJobRegistry(config.Workers)
+	limiter = NewRateLimiter(config.Auth.RequestsPerMinute, config.Auth.BytesPerMinute)
+
 	http.HandleFunc("/print", printHandler)
-	debugLog("Print handler setup completed.")
+	http.HandleFunc("/jobs", jobsListHandler)
+	http.HandleFunc("/jobs/", jobHandler)
+	http.HandleFunc("/printers", printersHandler)
+	appLog.Infof("", "Print handler setup completed.")
 
-	if config.RunOnHttps {
-		err := http.ListenAndServeTLS(":49155", "./certificates/server.crt", "./certificates/server.key", nil)
-		if err != nil {
-			log.Fatal("ListenAndServeTLS: ", err)
-		}
-	} else {
-		log.Fatal(http.ListenAndServe(":49155", nil))
-	}
+	runServerWithGracefulShutdown()
 }
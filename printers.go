@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// PrinterProfile describes one named printer the server can route jobs to.
+type PrinterProfile struct {
+	QueueName        string   `json:"queue_name"`
+	Driver           string   `json:"driver"`
+	DefaultOptions   []string `json:"default_options"`
+	AllowedMimeTypes []string `json:"allowed_mime_types"`
+}
+
+// resolvePrinter picks the printer profile for a request: an explicit
+// override (e.g. from the multipart "info" part) wins, then the ?printer=
+// query parameter, then the X-Printer header, then the configured default.
+// It returns the profile name and whether it was found.
+func resolvePrinter(req *http.Request, override string) (string, PrinterProfile, bool) {
+	name := override
+	if name == "" {
+		name = req.URL.Query().Get("printer")
+	}
+	if name == "" {
+		name = req.Header.Get("X-Printer")
+	}
+	if name == "" {
+		name = config.DefaultPrinter
+	}
+
+	profile, ok := config.Printers[name]
+	return name, profile, ok
+}
+
+// printerAccepts reports whether a profile's allow-list permits a content
+// type. An empty list accepts everything the server can convert.
+func printerAccepts(profile PrinterProfile, contentType string) bool {
+	if len(profile.AllowedMimeTypes) == 0 {
+		return true
+	}
+	for _, allowed := range profile.AllowedMimeTypes {
+		if allowed == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// PrinterStatus is the shape returned by GET /printers: the configured
+// profile plus a best-effort liveness probe.
+type PrinterStatus struct {
+	Name    string         `json:"name"`
+	Profile PrinterProfile `json:"profile"`
+	Online  bool           `json:"online"`
+	Status  string         `json:"status,omitempty"`
+}
+
+// printersHandler lists every configured printer along with a liveness
+// probe, e.g. GET /printers.
+func printersHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Only GET method is accepted", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireBearerToken(w, req) {
+		return
+	}
+
+	statuses := make([]PrinterStatus, 0, len(config.Printers))
+	for name, profile := range config.Printers {
+		online, status := probePrinter(profile.QueueName)
+		statuses = append(statuses, PrinterStatus{
+			Name:    name,
+			Profile: profile,
+			Online:  online,
+			Status:  status,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+// probePrinter shells out to the OS printing subsystem to check whether a
+// queue is reachable. A probe failure is treated as "offline" rather than
+// an error, since a misconfigured or temporarily unreachable printer
+// shouldn't break the /printers listing.
+func probePrinter(queueName string) (bool, string) {
+	if queueName == "" {
+		return false, "no queue name configured"
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("wmic", "printer", "where", "name='"+queueName+"'", "get", "PrinterStatus")
+	} else {
+		cmd = exec.Command("lpstat", "-p", queueName)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, strings.TrimSpace(string(output))
+	}
+	return true, strings.TrimSpace(string(output))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// runServerWithGracefulShutdown starts the HTTP server and blocks until a
+// termination signal arrives, at which point it stops accepting new
+// connections, waits for in-flight print jobs to drain, and shuts down
+// cleanly.
+func runServerWithGracefulShutdown() {
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", config.Port)}
+
+	go func() {
+		var err error
+		if config.RunOnHttps {
+			err = srv.ListenAndServeTLS("./certificates/server.crt", "./certificates/server.key")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			appLog.Fatalf("", "%v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	sig := <-quit
+	appLog.Infof("", "Received signal %v, shutting down...", sig)
+
+	shutdownTimeout := time.Duration(config.ShutdownTimeout) * time.Second
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+
+	// Shutdown and Drain each get the full configured timeout: a slow
+	// in-flight request eating into Shutdown's deadline must not shrink
+	// the window jobs get to drain afterward.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		appLog.Errorf("", "Error shutting down server: %v", err)
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelDrain()
+	if jobs != nil && !jobs.Drain(drainCtx) {
+		appLog.Warnf("", "Shutdown timeout reached with print jobs still in flight")
+	}
+
+	cleanupPrintJobs()
+	appLog.Infof("", "Server shut down cleanly.")
+}
+
+// writePidFile records the current process ID so process supervisors can
+// manage the server without scraping for it.
+func writePidFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// removePidFile deletes the PID file written at startup. Failing to find it
+// is not an error, since the file may already have been cleaned up.
+func removePidFile(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		appLog.Errorf("", "Failed to remove PID file %s: %v", path, err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// PrintInfo carries the per-job options sent alongside the uploaded file in
+// the multipart "info" part.
+type PrintInfo struct {
+	Copies      int    `json:"copies"`
+	Duplex      bool   `json:"duplex"`
+	Media       string `json:"media"`
+	Orientation string `json:"orientation"`
+	Printer     string `json:"printer"`
+}
+
+// Converter prepares an uploaded file for spooling and builds the OS
+// command that sends it to the printer. Each supported content type has
+// its own implementation.
+type Converter interface {
+	// Prepare returns the bytes that should be written to the spool file.
+	Prepare(data []byte) ([]byte, error)
+	// Command builds the command that hands the spooled file off to queueName.
+	Command(spoolPath, queueName string, info PrintInfo, profile PrinterProfile) *exec.Cmd
+}
+
+// converters maps a detected content type to the Converter that knows how
+// to handle it. Unknown types are rejected with 415.
+var converters = map[string]Converter{
+	"application/pdf": pdfConverter{},
+	"image/png":       imageConverter{},
+	"image/jpeg":      imageConverter{},
+	"application/zpl": passthroughConverter{},
+	"application/epl": passthroughConverter{},
+	"text/plain":      textConverter{},
+}
+
+// converterFor looks up the Converter for a detected content type.
+func converterFor(contentType string) (Converter, bool) {
+	c, ok := converters[contentType]
+	return c, ok
+}
+
+// detectFileType identifies the content type of an upload, preferring the
+// file extension for label-printer formats that http.DetectContentType
+// can't recognize, and falling back to content sniffing otherwise.
+func detectFileType(fileName string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".zpl":
+		return "application/zpl"
+	case ".epl":
+		return "application/epl"
+	}
+
+	contentType := http.DetectContentType(data)
+	if idx := strings.Index(contentType, ";"); idx != -1 {
+		contentType = contentType[:idx]
+	}
+	return contentType
+}
+
+// printOptions translates PrintInfo and a printer profile's default options
+// into CUPS `lp` flags.
+func printOptions(info PrintInfo, profile PrinterProfile) []string {
+	opts := append([]string{}, profile.DefaultOptions...)
+	if info.Copies > 0 {
+		opts = append(opts, "-n", strconv.Itoa(info.Copies))
+	}
+	if info.Duplex {
+		opts = append(opts, "-o", "sides=two-sided-long-edge")
+	}
+	if info.Media != "" {
+		opts = append(opts, "-o", "media="+info.Media)
+	}
+	if info.Orientation != "" {
+		opts = append(opts, "-o", "orientation-requested="+info.Orientation)
+	}
+	return opts
+}
+
+// pdfConverter spools PDFs directly: `lp -o ...` on Linux, SumatraPDF's
+// print-to mode on Windows.
+type pdfConverter struct{}
+
+func (pdfConverter) Prepare(data []byte) ([]byte, error) { return data, nil }
+
+func (pdfConverter) Command(spoolPath, queueName string, info PrintInfo, profile PrinterProfile) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("SumatraPDF", "-print-to", queueName, spoolPath)
+	}
+	args := append([]string{"-d", queueName}, printOptions(info, profile)...)
+	args = append(args, spoolPath)
+	return exec.Command("lp", args...)
+}
+
+// imageConverter prints PNG/JPEG uploads scaled to fit the page via
+// `lp -o fit-to-page`. On Windows the file is copied to the queue as-is,
+// without rasterizing or scaling.
+type imageConverter struct{}
+
+func (imageConverter) Prepare(data []byte) ([]byte, error) { return data, nil }
+
+func (imageConverter) Command(spoolPath, queueName string, info PrintInfo, profile PrinterProfile) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/c", "copy", spoolPath, queueName)
+	}
+	args := append([]string{"-d", queueName}, printOptions(info, profile)...)
+	args = append(args, "-o", "fit-to-page", spoolPath)
+	return exec.Command("lp", args...)
+}
+
+// passthroughConverter spools printer-native bytes (ZPL/EPL) unmodified. On
+// CUPS, that means forcing `-o raw` so the backend doesn't try to filter or
+// auto-detect the content type and mangle the label data.
+type passthroughConverter struct{}
+
+func (passthroughConverter) Prepare(data []byte) ([]byte, error) { return data, nil }
+
+func (passthroughConverter) Command(spoolPath, queueName string, info PrintInfo, profile PrinterProfile) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return buildPrintCommand(queueName, spoolPath)
+	}
+	if queueName == "" {
+		return exec.Command("lp", "-o", "raw", spoolPath)
+	}
+	return exec.Command("lp", "-d", queueName, "-o", "raw", spoolPath)
+}
+
+// textConverter wraps plain text with a trailing form-feed so it ejects the
+// page, then spools it with the same copies/duplex/media/orientation
+// handling as the PDF and image converters.
+type textConverter struct{}
+
+func (textConverter) Prepare(data []byte) ([]byte, error) {
+	return append(data, '\f'), nil
+}
+
+func (textConverter) Command(spoolPath, queueName string, info PrintInfo, profile PrinterProfile) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return buildPrintCommand(queueName, spoolPath)
+	}
+	args := append([]string{"-d", queueName}, printOptions(info, profile)...)
+	args = append(args, spoolPath)
+	return exec.Command("lp", args...)
+}